@@ -0,0 +1,184 @@
+// Package diffpath walks two protoreflect messages field-by-field and
+// reports every point where they disagree as a path-addressed record
+// (e.g. outer.nested[2].map["k"].field17), instead of two opaque text
+// blobs. It backs the -diff=json roundtrip mismatch report shared by
+// harness and harness_dynamic.
+package diffpath
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Kind classifies one record produced by Diff.
+type Kind string
+
+const (
+	KindValueMismatch       Kind = "value_mismatch"
+	KindMissingLHS          Kind = "missing_lhs"
+	KindMissingRHS          Kind = "missing_rhs"
+	KindUnknownFieldsDiffer Kind = "unknown_field_bytes_differ"
+)
+
+// Mismatch is one entry in a structured field-path diff between two
+// messages, e.g. path=outer.nested[2].map["k"].field17.
+type Mismatch struct {
+	Path string `json:"path"`
+	Kind Kind   `json:"kind"`
+	LHS  string `json:"lhs,omitempty"`
+	RHS  string `json:"rhs,omitempty"`
+}
+
+// Diff recursively walks lhs and rhs field-by-field, returning a
+// path-addressed list of every mismatch rather than two opaque text blobs,
+// so a fuzz driver can dedupe and minimize against a stable signature.
+// Only the fields declared on the message descriptor are walked; extension
+// fields are not compared.
+func Diff(path string, lhs, rhs protoreflect.Message) []Mismatch {
+	var out []Mismatch
+
+	if lu, ru := lhs.GetUnknown(), rhs.GetUnknown(); !bytes.Equal(lu, ru) {
+		out = append(out, Mismatch{
+			Path: fieldPathOrRoot(path),
+			Kind: KindUnknownFieldsDiffer,
+			LHS:  fmt.Sprintf("%x", lu),
+			RHS:  fmt.Sprintf("%x", ru),
+		})
+	}
+
+	fields := lhs.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := joinPath(path, string(fd.Name()))
+		hasL, hasR := lhs.Has(fd), rhs.Has(fd)
+
+		switch {
+		case !hasL && !hasR:
+			continue
+		case fd.IsList() || fd.IsMap():
+			// An absent list or map reads as empty, so it is compared
+			// element-by-element below rather than rendered whole.
+		case hasL && !hasR:
+			out = append(out, Mismatch{Path: fieldPath, Kind: KindMissingRHS, LHS: formatFieldValue(fd, lhs.Get(fd))})
+			continue
+		case !hasL && hasR:
+			out = append(out, Mismatch{Path: fieldPath, Kind: KindMissingLHS, RHS: formatFieldValue(fd, rhs.Get(fd))})
+			continue
+		}
+
+		lv, rv := lhs.Get(fd), rhs.Get(fd)
+		switch {
+		case fd.IsMap():
+			out = append(out, diffMap(fieldPath, fd, lv.Map(), rv.Map())...)
+		case fd.IsList():
+			out = append(out, diffList(fieldPath, fd, lv.List(), rv.List())...)
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			out = append(out, Diff(fieldPath, lv.Message(), rv.Message())...)
+		default:
+			if !lv.Equal(rv) {
+				out = append(out, Mismatch{Path: fieldPath, Kind: KindValueMismatch, LHS: formatFieldValue(fd, lv), RHS: formatFieldValue(fd, rv)})
+			}
+		}
+	}
+
+	return out
+}
+
+// diffMap diffs a map field entry-by-entry, keyed by map[key] path
+// segments, descending into message-typed values.
+func diffMap(path string, fd protoreflect.FieldDescriptor, lhs, rhs protoreflect.Map) []Mismatch {
+	valueFd := fd.MapValue()
+	var out []Mismatch
+	seen := make(map[interface{}]bool)
+
+	lhs.Range(func(k protoreflect.MapKey, lv protoreflect.Value) bool {
+		seen[k.Interface()] = true
+		keyPath := fmt.Sprintf("%s[%q]", path, k.String())
+		if !rhs.Has(k) {
+			out = append(out, Mismatch{Path: keyPath, Kind: KindMissingRHS, LHS: formatFieldValue(valueFd, lv)})
+			return true
+		}
+		out = append(out, diffScalarOrMessage(keyPath, valueFd, lv, rhs.Get(k))...)
+		return true
+	})
+	rhs.Range(func(k protoreflect.MapKey, rv protoreflect.Value) bool {
+		if seen[k.Interface()] {
+			return true
+		}
+		keyPath := fmt.Sprintf("%s[%q]", path, k.String())
+		out = append(out, Mismatch{Path: keyPath, Kind: KindMissingLHS, RHS: formatFieldValue(valueFd, rv)})
+		return true
+	})
+	return out
+}
+
+// diffList diffs a repeated field element-by-element, keyed by [index]
+// path segments, descending into message-typed elements.
+func diffList(path string, fd protoreflect.FieldDescriptor, lhs, rhs protoreflect.List) []Mismatch {
+	var out []Mismatch
+	n := lhs.Len()
+	if rhs.Len() > n {
+		n = rhs.Len()
+	}
+	for i := 0; i < n; i++ {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= lhs.Len():
+			out = append(out, Mismatch{Path: elemPath, Kind: KindMissingLHS, RHS: formatFieldValue(fd, rhs.Get(i))})
+		case i >= rhs.Len():
+			out = append(out, Mismatch{Path: elemPath, Kind: KindMissingRHS, LHS: formatFieldValue(fd, lhs.Get(i))})
+		default:
+			out = append(out, diffScalarOrMessage(elemPath, fd, lhs.Get(i), rhs.Get(i))...)
+		}
+	}
+	return out
+}
+
+// diffScalarOrMessage compares a single map value or list element, either
+// recursing into Diff (for message-typed fields) or comparing the scalar
+// value directly.
+func diffScalarOrMessage(path string, fd protoreflect.FieldDescriptor, lv, rv protoreflect.Value) []Mismatch {
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return Diff(path, lv.Message(), rv.Message())
+	}
+	if !lv.Equal(rv) {
+		return []Mismatch{{Path: path, Kind: KindValueMismatch, LHS: formatFieldValue(fd, lv), RHS: formatFieldValue(fd, rv)}}
+	}
+	return nil
+}
+
+// joinPath appends a field name to a dotted path, omitting the leading dot
+// at the root.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// fieldPathOrRoot renders path for display, using "<root>" in place of the
+// empty path at the top of the message.
+func fieldPathOrRoot(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+// formatFieldValue renders a single field's value as a short string for a
+// diff record: the textproto form of a message-typed value, or fmt's
+// default rendering of a scalar.
+func formatFieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		b, err := prototext.MarshalOptions{}.Marshal(v.Message().Interface())
+		if err != nil {
+			return fmt.Sprintf("<marshal error: %v>", err)
+		}
+		return strings.TrimSpace(string(b))
+	}
+	return fmt.Sprint(v.Interface())
+}
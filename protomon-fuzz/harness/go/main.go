@@ -12,22 +12,84 @@
 //
 //	# Roundtrip test (encode then decode, compare):
 //	./harness -mode=roundtrip < input.textproto > output.bin
+//
+//	# Batch modes: process many records from one process, amortizing the
+//	# per-invocation cost across a whole fuzz corpus. Binary records are
+//	# varint length-delimited (the wire format used by
+//	# encoding/protodelim); text and json records are separated by a line
+//	# containing only "---".
+//	./harness -mode=encode-stream < corpus.textprotos > corpus.bin
+//	./harness -mode=decode-stream < corpus.bin > corpus.textprotos
+//	./harness -mode=roundtrip-stream -k < corpus.textprotos > corpus.bin
+//
+//	# -in/-out pick the codec on either side of a mode, independently of
+//	# the mode's usual direction, enabling differential fuzzing across
+//	# any pair of formats (e.g. json<->binary, text<->json):
+//	./harness -mode=encode -in=json -out=binary < input.json > output.bin
+//	./harness -mode=roundtrip -in=json -out=text < input.json > output.textproto
+//
+//	# Cross-implementation differential test: encode with
+//	# google.golang.org/protobuf, then decode the same bytes with
+//	# gogo/protobuf (and, if -peer is set, an out-of-process harness
+//	# speaking the size-delimited streaming protocol from -mode=*-stream)
+//	# and diff the results.
+//	./harness -mode=differential < input.textproto
+//	./harness -mode=differential -peer=./other_harness < input.textproto
+//
+//	# On a roundtrip mismatch, report a structured {path,kind,lhs,rhs}
+//	# record per differing field on stderr (one JSON object per line)
+//	# instead of two full textprotos, so fuzz drivers can dedupe and
+//	# minimize crashes against a stable signature:
+//	./harness -mode=roundtrip -diff=json < input.textproto > output.bin
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
+	gogoproto "github.com/gogo/protobuf/proto"
+
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 
+	"protomon-fuzz-harness/internal/diffpath"
 	pb "protomon-fuzz-harness/proto"
 )
 
 const maxInputSize = 100 * 1024 * 1024 // 100MB
 
+// recordSeparator delimits text and json records in the streaming format: a
+// line containing exactly this string and nothing else.
+const recordSeparator = "---"
+
+// maxVarintBytes is the longest a base-128 varint can be before it must be
+// malformed (ceil(64/7)).
+const maxVarintBytes = 10
+
+// Format identifiers accepted by -in and -out.
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatBinary = "binary"
+)
+
+// formatDiffJSON is the -diff value that switches roundtrip mismatch
+// reporting from two full textprotos to structured per-field records.
+const formatDiffJSON = "json"
+
 // MessageFactory creates a new proto.Message instance.
 type MessageFactory func() proto.Message
 
@@ -37,12 +99,24 @@ var prettyTextOptions = prototext.MarshalOptions{
 	Indent:    "  ",
 }
 
+// Consistent JSON format options for pretty printing.
+var prettyJSONOptions = protojson.MarshalOptions{
+	Multiline: true,
+	Indent:    "  ",
+}
+
 var (
-	mode        = flag.String("mode", "encode", "Mode: 'encode' (text->binary), 'decode' (binary->text), or 'roundtrip'")
+	mode        = flag.String("mode", "encode", "Mode: 'encode', 'decode', 'roundtrip', the '-stream' variant of each, or 'differential'")
 	messageType = flag.String("message", "TestMessage", "Message type: 'TestMessage' or 'NestedExample'")
+	keepGoing   = flag.Bool("keep-going", false, "in -stream modes, report per-record errors to stderr and keep processing instead of stopping at the first one")
+	inFormat    = flag.String("in", "", "Input format: 'text', 'json', or 'binary' (default depends on -mode)")
+	outFormat   = flag.String("out", "", "Output format: 'text', 'json', or 'binary' (default depends on -mode)")
+	peer        = flag.String("peer", "", "in -mode=differential, path to an additional out-of-process harness binary to decode against, invoked as a '-mode=decode-stream' peer")
+	diffFormat  = flag.String("diff", "text", "Roundtrip mismatch report format: 'text' (two full textprotos) or 'json' (structured {path,kind,lhs,rhs} records on stderr, one per line)")
 )
 
 func main() {
+	flag.BoolVar(keepGoing, "k", false, "shorthand for -keep-going")
 	flag.Parse()
 
 	var err error
@@ -76,97 +150,140 @@ func readLimited(r io.Reader) ([]byte, error) {
 	return data, nil
 }
 
-func run(mode string, newMsg MessageFactory) error {
+// resolveFormats fills in -in/-out defaults for modes that didn't specify
+// them explicitly, preserving each mode's historical direction.
+func resolveFormats(mode string) (in, out string) {
+	in, out = *inFormat, *outFormat
 	switch mode {
-	case "encode":
-		return encode(newMsg)
-	case "decode":
-		return decode(newMsg)
-	case "roundtrip":
-		return roundtrip(newMsg)
-	default:
-		return fmt.Errorf("unknown mode: %s", mode)
+	case "encode", "encode-stream":
+		if in == "" {
+			in = formatText
+		}
+		if out == "" {
+			out = formatBinary
+		}
+	case "decode", "decode-stream":
+		if in == "" {
+			in = formatBinary
+		}
+		if out == "" {
+			out = formatText
+		}
+	case "roundtrip", "roundtrip-stream":
+		if in == "" {
+			in = formatText
+		}
+		if out == "" {
+			out = formatBinary
+		}
 	}
+	return in, out
 }
 
-func encode(newMsg MessageFactory) error {
-	// Read text format from stdin with size limit
-	textInput, err := readLimited(os.Stdin)
-	if err != nil {
-		return fmt.Errorf("failed to read stdin: %w", err)
-	}
-
-	// Parse text format
-	msg := newMsg()
-	if err := prototext.Unmarshal(textInput, msg); err != nil {
-		return fmt.Errorf("failed to parse text format: %w", err)
+// unmarshalMsg decodes data into msg using the given format.
+func unmarshalMsg(format string, data []byte, msg proto.Message) error {
+	switch format {
+	case formatText:
+		return prototext.Unmarshal(data, msg)
+	case formatJSON:
+		return protojson.Unmarshal(data, msg)
+	case formatBinary:
+		return proto.Unmarshal(data, msg)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
 	}
+}
 
-	// Serialize to binary
-	binaryOutput, err := proto.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to serialize: %w", err)
+// marshalMsg encodes msg using the given format.
+func marshalMsg(format string, msg proto.Message) ([]byte, error) {
+	switch format {
+	case formatText:
+		return prettyTextOptions.Marshal(msg)
+	case formatJSON:
+		return prettyJSONOptions.Marshal(msg)
+	case formatBinary:
+		return proto.Marshal(msg)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
 	}
+}
 
-	// Write binary to stdout
-	if _, err := os.Stdout.Write(binaryOutput); err != nil {
-		return fmt.Errorf("failed to write output: %w", err)
+func run(mode string, newMsg MessageFactory) error {
+	in, out := resolveFormats(mode)
+	switch mode {
+	case "encode", "decode":
+		return convert(newMsg, in, out)
+	case "roundtrip":
+		return roundtrip(newMsg, in, out)
+	case "encode-stream", "decode-stream":
+		return convertStream(newMsg, in, out)
+	case "roundtrip-stream":
+		return roundtripStream(newMsg, in, out)
+	case "differential":
+		return differential(newMsg, *messageType, *peer)
+	default:
+		return fmt.Errorf("unknown mode: %s", mode)
 	}
-
-	return nil
 }
 
-func decode(newMsg MessageFactory) error {
-	// Read binary from stdin with size limit
-	binaryInput, err := readLimited(os.Stdin)
+// convert reads one record in format in from stdin and writes it re-encoded
+// in format out to stdout. This backs both -mode=encode and -mode=decode,
+// which differ only in their default formats.
+func convert(newMsg MessageFactory, in, out string) error {
+	data, err := readLimited(os.Stdin)
 	if err != nil {
 		return fmt.Errorf("failed to read stdin: %w", err)
 	}
 
-	// Parse binary format
 	msg := newMsg()
-	if err := proto.Unmarshal(binaryInput, msg); err != nil {
-		return fmt.Errorf("failed to parse binary: %w", err)
+	if err := unmarshalMsg(in, data, msg); err != nil {
+		return fmt.Errorf("failed to parse %s input: %w", in, err)
 	}
 
-	// Print as text format using consistent options
-	textOutput, err := prettyTextOptions.Marshal(msg)
+	encoded, err := marshalMsg(out, msg)
 	if err != nil {
-		return fmt.Errorf("failed to print text format: %w", err)
+		return fmt.Errorf("failed to marshal %s output: %w", out, err)
 	}
 
-	fmt.Print(string(textOutput))
+	if _, err := os.Stdout.Write(encoded); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
 	return nil
 }
 
-func roundtrip(newMsg MessageFactory) error {
-	// Read text format from stdin with size limit
-	textInput, err := readLimited(os.Stdin)
+// roundtrip parses one record in format in, re-encodes it through a binary
+// intermediate, and checks that decoding the intermediate reproduces the
+// same message, before writing it out in format out. Differing in/out
+// formats turn this into a cross-format differential check (e.g.
+// -in=json -out=text exercises json->binary->text).
+func roundtrip(newMsg MessageFactory, in, out string) error {
+	data, err := readLimited(os.Stdin)
 	if err != nil {
 		return fmt.Errorf("failed to read stdin: %w", err)
 	}
 
-	// Parse text format
 	msg1 := newMsg()
-	if err := prototext.Unmarshal(textInput, msg1); err != nil {
-		return fmt.Errorf("failed to parse text format: %w", err)
+	if err := unmarshalMsg(in, data, msg1); err != nil {
+		return fmt.Errorf("failed to parse %s input: %w", in, err)
 	}
 
-	// Serialize to binary
 	binary, err := proto.Marshal(msg1)
 	if err != nil {
 		return fmt.Errorf("failed to serialize: %w", err)
 	}
 
-	// Parse binary back
 	msg2 := newMsg()
 	if err := proto.Unmarshal(binary, msg2); err != nil {
 		return fmt.Errorf("failed to parse binary: %w", err)
 	}
 
-	// Compare
 	if !proto.Equal(msg1, msg2) {
-		// Handle potential marshal errors in error reporting
+		if *diffFormat == formatDiffJSON {
+			return reportJSONDiff(msg1, msg2)
+		}
+
+		// Default: show the mismatch as text, regardless of in/out, since
+		// it's for human debugging.
 		text1, err1 := prettyTextOptions.Marshal(msg1)
 		text2, err2 := prettyTextOptions.Marshal(msg2)
 
@@ -184,11 +301,558 @@ func roundtrip(newMsg MessageFactory) error {
 			originalText, roundtripText)
 	}
 
-	// Output the binary
-	if _, err := os.Stdout.Write(binary); err != nil {
+	encoded, err := marshalMsg(out, msg2)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s output: %w", out, err)
+	}
+	if _, err := os.Stdout.Write(encoded); err != nil {
 		return fmt.Errorf("failed to write output: %w", err)
 	}
 
 	fmt.Fprintf(os.Stderr, "Roundtrip OK (%d bytes)\n", len(binary))
 	return nil
 }
+
+// reportJSONDiff emits one structured {path,kind,lhs,rhs} record per line to
+// stderr for every field where lhs and rhs disagree, then returns a summary
+// error. This gives fuzz drivers a stable signature to dedupe and minimize
+// crashes against, instead of diffing two opaque textproto blobs.
+func reportJSONDiff(lhs, rhs proto.Message) error {
+	mismatches := diffpath.Diff("", lhs.ProtoReflect(), rhs.ProtoReflect())
+
+	enc := json.NewEncoder(os.Stderr)
+	for _, m := range mismatches {
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("failed to encode diff record: %w", err)
+		}
+	}
+
+	return fmt.Errorf("roundtrip mismatch: %d field path(s) differ (see -diff=json records on stderr)", len(mismatches))
+}
+
+// convertStream reads a sequence of records in format in from stdin and
+// writes each re-encoded in format out to stdout. This backs both
+// -mode=encode-stream and -mode=decode-stream.
+func convertStream(newMsg MessageFactory, in, out string) error {
+	records, err := readStreamRecords(in, os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	var written, failed int
+	for i, rec := range records {
+		msg := newMsg()
+		if err := unmarshalMsg(in, rec, msg); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to parse %s input: %v\n", i, in, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		encoded, err := marshalMsg(out, msg)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to marshal %s output: %v\n", i, out, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		if err := writeStreamRecord(w, out, written, encoded); err != nil {
+			return fmt.Errorf("record %d: failed to write output: %w", i, err)
+		}
+		written++
+	}
+
+	fmt.Fprintf(os.Stderr, "%d/%d records OK\n", len(records)-failed, len(records))
+	if failed > 0 {
+		return fmt.Errorf("%d/%d records failed", failed, len(records))
+	}
+	return nil
+}
+
+// roundtripStream runs the roundtrip check independently over each record
+// in an in-format stream, writing the re-encoded out-format output for
+// every record that round-trips cleanly.
+func roundtripStream(newMsg MessageFactory, in, out string) error {
+	records, err := readStreamRecords(in, os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	var written, failed int
+	for i, rec := range records {
+		msg1 := newMsg()
+		if err := unmarshalMsg(in, rec, msg1); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to parse %s input: %v\n", i, in, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		binary, err := proto.Marshal(msg1)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to serialize: %v\n", i, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		msg2 := newMsg()
+		if err := proto.Unmarshal(binary, msg2); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to parse binary: %v\n", i, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		if !proto.Equal(msg1, msg2) {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: roundtrip mismatch\n", i)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		encoded, err := marshalMsg(out, msg2)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to marshal %s output: %v\n", i, out, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+		if err := writeStreamRecord(w, out, written, encoded); err != nil {
+			return fmt.Errorf("record %d: failed to write output: %w", i, err)
+		}
+		written++
+	}
+
+	fmt.Fprintf(os.Stderr, "%d/%d records OK\n", len(records)-failed, len(records))
+	if failed > 0 {
+		return fmt.Errorf("%d/%d records failed", failed, len(records))
+	}
+	return nil
+}
+
+// readStreamRecords reads every record from r according to format, returning
+// each as raw encoded bytes ready for unmarshalMsg.
+func readStreamRecords(format string, r io.Reader) ([][]byte, error) {
+	if format == formatBinary {
+		var records [][]byte
+		for {
+			rec, err := readDelimited(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+		return records, nil
+	}
+
+	data, err := readLimited(r)
+	if err != nil {
+		return nil, err
+	}
+	return splitTextRecords(data), nil
+}
+
+// writeStreamRecord writes one record already encoded in format to w. index
+// is the number of records already written successfully, used to decide
+// whether a separator is needed before this one.
+func writeStreamRecord(w io.Writer, format string, index int, data []byte) error {
+	if format == formatBinary {
+		return writeDelimited(w, data)
+	}
+	if index > 0 {
+		if _, err := fmt.Fprintln(w, recordSeparator); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitTextRecords splits data into text or json records delimited by a
+// line containing only recordSeparator.
+func splitTextRecords(data []byte) [][]byte {
+	var records [][]byte
+	var cur bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == recordSeparator {
+			records = append(records, append([]byte(nil), cur.Bytes()...))
+			cur.Reset()
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+	}
+	if cur.Len() > 0 {
+		records = append(records, append([]byte(nil), cur.Bytes()...))
+	}
+	return records
+}
+
+// writeDelimited writes msg to w prefixed with its length as a base-128
+// varint, matching the wire format read by encoding/protodelim.
+func writeDelimited(w io.Writer, msg []byte) error {
+	header := protowire.AppendVarint(nil, uint64(len(msg)))
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write length header: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write payload: %w", err)
+	}
+	return nil
+}
+
+// readDelimited reads one varint length-delimited record from r. The
+// length header is read strictly one byte at a time and unbuffered (no
+// bufio.Reader), so reading a header never pulls in bytes past it; a
+// record whose length prefix is itself corrupt still takes its payload
+// from whatever bytes follow. Returns io.EOF only when zero bytes
+// could be read for a new record's header.
+func readDelimited(r io.Reader) ([]byte, error) {
+	size, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxInputSize {
+		return nil, fmt.Errorf("record size %d exceeds maximum of %d bytes", size, maxInputSize)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("failed to read %d-byte payload: %w", size, err)
+	}
+	return payload, nil
+}
+
+// readVarint decodes a single base-128 varint from r, reading exactly one
+// byte at a time via individual r.Read calls.
+func readVarint(r io.Reader) (uint64, error) {
+	var buf []byte
+	var b [1]byte
+	for len(buf) < maxVarintBytes {
+		n, err := r.Read(b[:])
+		if n == 0 {
+			if err != nil {
+				if err == io.EOF && len(buf) == 0 {
+					return 0, io.EOF
+				}
+				return 0, fmt.Errorf("truncated varint header: %w", err)
+			}
+			continue
+		}
+		buf = append(buf, b[0])
+
+		if v, n := protowire.ConsumeVarint(buf); n > 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("malformed varint: exceeded %d bytes", maxVarintBytes)
+}
+
+// differential reads one textproto record, encodes it with
+// google.golang.org/protobuf, and decodes the resulting bytes with every
+// alternate backend available (gogo/protobuf always, plus -peer if set),
+// diffing each backend's rendering against the google.golang.org/protobuf
+// reference. It turns the harness from a self-roundtrip check into a
+// cross-implementation oracle.
+func differential(newMsg MessageFactory, messageType, peerPath string) error {
+	textInput, err := readLimited(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	refMsg := newMsg()
+	if err := prototext.Unmarshal(textInput, refMsg); err != nil {
+		return fmt.Errorf("failed to parse text format: %w", err)
+	}
+
+	binary, err := proto.Marshal(refMsg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize: %w", err)
+	}
+
+	refText, refUnknown := canonicalForm(refMsg)
+
+	type backendResult struct {
+		name    string
+		text    string
+		unknown []byte
+	}
+	var results []backendResult
+
+	gogoMsg, err := newGogoMessage(messageType)
+	if err != nil {
+		return fmt.Errorf("gogo/protobuf backend: %w", err)
+	}
+	if err := gogoproto.Unmarshal(binary, gogoMsg); err != nil {
+		return fmt.Errorf("gogo/protobuf backend: failed to decode: %w", err)
+	}
+	results = append(results, backendResult{
+		name:    "gogo/protobuf",
+		text:    normalizeTextForm(gogoproto.MarshalTextString(gogoMsg)),
+		unknown: gogoUnknownBytes(gogoMsg),
+	})
+
+	if peerPath != "" {
+		peerText, err := decodeWithPeer(peerPath, binary, messageType)
+		if err != nil {
+			return fmt.Errorf("peer backend %s: %w", peerPath, err)
+		}
+		results = append(results, backendResult{
+			name: peerPath,
+			text: normalizeTextForm(peerText),
+			// The peer speaks textproto over the streaming protocol,
+			// which never round-trips unknown fields back to us, so
+			// there's nothing to bucket for this backend.
+		})
+	}
+
+	normalizedRef := normalizeTextForm(refText)
+	var mismatches []string
+	for _, r := range results {
+		if r.text == normalizedRef && bytes.Equal(r.unknown, refUnknown) {
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf(
+			"--- google.golang.org/protobuf (reference)\n+++ %s\n%sunknown fields: reference=%x %s=%x",
+			r.name, unifiedDiff(normalizedRef, r.text), refUnknown, r.name, r.unknown))
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("differential mismatch across %d/%d backend(s):\n\n%s",
+			len(mismatches), len(results), strings.Join(mismatches, "\n\n"))
+	}
+
+	fmt.Fprintf(os.Stderr, "Differential OK across %d backend(s)\n", len(results))
+	return nil
+}
+
+// canonicalForm splits msg into a comparison-stable text rendering and its
+// raw unknown-field bytes, so that surviving-but-undecoded data doesn't get
+// diffed inline with the known fields.
+func canonicalForm(msg proto.Message) (text string, unknown []byte) {
+	clone := proto.Clone(msg)
+	unknown = append([]byte(nil), clone.ProtoReflect().GetUnknown()...)
+	clone.ProtoReflect().SetUnknown(nil)
+
+	textBytes, err := prettyTextOptions.Marshal(clone)
+	if err != nil {
+		return fmt.Sprintf("<marshal error: %v>", err), unknown
+	}
+	return string(textBytes), unknown
+}
+
+// floatToken matches a bare floating point literal for reformatting.
+var floatToken = regexp.MustCompile(`-?\d+\.\d+(?:[eE][-+]?\d+)?`)
+
+// normalizeTextForm canonicalizes a textproto-shaped rendering for
+// cross-implementation comparison: float literals are reformatted through
+// strconv to a single representation, message delimiters are normalized to
+// "{"/"}" (gogo/protobuf's default text marshaler uses the older "<"/">"
+// style for the same thing), and sibling lines within each scope are
+// sorted, since map entries (and some repeated fields) aren't guaranteed
+// to serialize in the same order across protobuf implementations.
+func normalizeTextForm(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		line = normalizeDelimiters(line)
+		lines = append(lines, floatToken.ReplaceAllStringFunc(line, normalizeFloatToken))
+	}
+	return strings.Join(sortScope(lines), "\n")
+}
+
+// normalizeDelimiters rewrites a gogo/protobuf-style "field <" / ">" message
+// delimiter to the "field {" / "}" form every other backend here uses, so
+// otherwise-identical messages don't get flagged as mismatched purely over
+// delimiter style.
+func normalizeDelimiters(line string) string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == ">":
+		return strings.Replace(line, ">", "}", 1)
+	case strings.HasSuffix(trimmed, "<"):
+		idx := strings.LastIndex(line, "<")
+		return line[:idx] + "{"
+	default:
+		return line
+	}
+}
+
+func normalizeFloatToken(tok string) string {
+	f, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return tok
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sortScope sorts the direct children of a brace-delimited scope
+// (individual field lines, or a nested "field { ... }" block kept intact
+// as one unit) so that emission order within the scope doesn't affect the
+// comparison.
+func sortScope(lines []string) []string {
+	var items []string
+	for i := 0; i < len(lines); {
+		if strings.HasSuffix(strings.TrimSpace(lines[i]), "{") {
+			depth := 1
+			j := i + 1
+			for ; j < len(lines) && depth > 0; j++ {
+				switch strings.TrimSpace(lines[j]) {
+				case "}":
+					depth--
+				default:
+					if strings.HasSuffix(strings.TrimSpace(lines[j]), "{") {
+						depth++
+					}
+				}
+			}
+			block := append([]string{lines[i]}, sortScope(lines[i+1:j-1])...)
+			block = append(block, lines[j-1])
+			items = append(items, strings.Join(block, "\n"))
+			i = j
+			continue
+		}
+		items = append(items, lines[i])
+		i++
+	}
+
+	sort.Strings(items)
+
+	var out []string
+	for _, item := range items {
+		out = append(out, strings.Split(item, "\n")...)
+	}
+	return out
+}
+
+// unifiedDiff renders a minimal line-by-line unified-style diff between a
+// and b, without pulling in an external diff library.
+func unifiedDiff(a, b string) string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	max := len(aLines)
+	if len(bLines) > max {
+		max = len(bLines)
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < max; i++ {
+		haveA, haveB := i < len(aLines), i < len(bLines)
+		switch {
+		case haveA && haveB && aLines[i] == bLines[i]:
+			fmt.Fprintf(&buf, " %s\n", aLines[i])
+		case haveA && haveB:
+			fmt.Fprintf(&buf, "-%s\n+%s\n", aLines[i], bLines[i])
+		case haveA:
+			fmt.Fprintf(&buf, "-%s\n", aLines[i])
+		case haveB:
+			fmt.Fprintf(&buf, "+%s\n", bLines[i])
+		}
+	}
+	return buf.String()
+}
+
+// newGogoMessage looks up a gogo/protobuf message registered under the
+// same fully-qualified name as messageType via reflection, mirroring how
+// google.golang.org/protobuf resolves dynamic message types by name.
+func newGogoMessage(messageType string) (gogoproto.Message, error) {
+	fqName := "protomon.fuzz." + messageType
+	t := gogoproto.MessageType(fqName)
+	if t == nil {
+		return nil, fmt.Errorf("no gogo/protobuf message registered for %q", fqName)
+	}
+	msg, ok := reflect.New(t.Elem()).Interface().(gogoproto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%q is registered but does not implement gogo/protobuf proto.Message", fqName)
+	}
+	return msg, nil
+}
+
+// gogoUnknownBytes extracts the raw unrecognized-field bytes gogo/protobuf
+// stashes on every generated message, via its conventional
+// XXX_unrecognized field.
+func gogoUnknownBytes(msg gogoproto.Message) []byte {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	f := v.Elem().FieldByName("XXX_unrecognized")
+	if !f.IsValid() {
+		return nil
+	}
+	b, _ := f.Interface().([]byte)
+	return b
+}
+
+// decodeWithPeer hands binary to an out-of-process harness built from this
+// same codebase, invoked as "-mode=decode-stream", and returns its
+// textproto output. The peer is given exactly one size-delimited record on
+// stdin (the protocol -mode=*-stream speaks on the binary side) and is
+// expected to emit its decoded textproto on stdout.
+func decodeWithPeer(peerPath string, binary []byte, messageType string) (string, error) {
+	cmd := exec.Command(peerPath, "-mode=decode-stream", "-message="+messageType)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to open peer stdin: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start peer: %w", err)
+	}
+
+	writeErr := writeDelimited(stdin, binary)
+	stdin.Close()
+	if writeErr != nil {
+		cmd.Wait()
+		return "", fmt.Errorf("failed to write record to peer: %w", writeErr)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("peer exited with error: %w\n%s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
@@ -1,7 +1,11 @@
 // Dynamic protobuf harness for protomon-fuzz.
 //
-// This harness can load any .proto file at runtime using protoc to generate
-// a FileDescriptorSet, then uses dynamicpb to work with the messages.
+// This harness can load any .proto file at runtime and uses dynamicpb to
+// work with the messages. By default it compiles the .proto itself with
+// the pure-Go github.com/bufbuild/protocompile library (--compiler=protocompile,
+// the default): no protoc installation, subprocess, or temp files required,
+// which keeps the harness hermetic in sandboxed fuzzing environments.
+// Pass --compiler=protoc to shell out to a protoc binary instead.
 //
 // Usage:
 //
@@ -13,66 +17,175 @@
 //
 //	# Roundtrip test:
 //	./harness_dynamic --mode=roundtrip --proto=schema.proto --message=package.MessageName < input.textproto > output.bin
+//
+//	# Batch modes, for amortizing schema compilation and process startup
+//	# across a whole fuzz corpus. Binary records are varint length-delimited (the wire
+//	# format used by encoding/protodelim); text and json records are
+//	# separated by a line containing only "---".
+//	./harness_dynamic --mode=encode-stream --proto=schema.proto --message=package.MessageName < corpus.textprotos > corpus.bin
+//	./harness_dynamic --mode=decode-stream --proto=schema.proto --message=package.MessageName < corpus.bin > corpus.textprotos
+//	./harness_dynamic --mode=roundtrip-stream -k --proto=schema.proto --message=package.MessageName < corpus.textprotos > corpus.bin
+//
+//	# --in/--out pick the codec on either side of a mode independently of
+//	# its usual direction, enabling cross-format differential fuzzing
+//	# (json<->binary, text<->json, ...) against a message loaded at
+//	# runtime:
+//	./harness_dynamic --mode=roundtrip --in=json --out=text --proto=schema.proto --message=package.MessageName < input.json
+//
+//	# Skip compilation entirely: pass a pre-built FileDescriptorSet (as
+//	# produced by protoc's own --descriptor_set_out flag, or by this
+//	# harness's own --mode=compile below). Otherwise, the compiled
+//	# descriptor set for a given --proto is cached under
+//	# $XDG_CACHE_HOME/protomon/descriptors so repeated invocations
+//	# against the same schema skip compilation entirely.
+//	./harness_dynamic --mode=decode --descriptor_set_in=schema.pb --message=package.MessageName < input.bin
+//
+//	# Prebuild the descriptor cache for CI, or produce a descriptor set
+//	# for use with --descriptor_set_in elsewhere:
+//	./harness_dynamic --mode=compile --proto=schema.proto > schema.pb
+//
+//	# On a roundtrip mismatch, report a structured {path,kind,lhs,rhs}
+//	# record per differing field on stderr (one JSON object per line)
+//	# instead of two full textprotos, so fuzz drivers can dedupe and
+//	# minimize crashes against a stable signature:
+//	./harness_dynamic --mode=roundtrip --diff=json --proto=schema.proto --message=package.MessageName < input.textproto
+//
+// Unlike harness, this binary has no --mode=differential: that mode
+// cross-checks google.golang.org/protobuf against the gogo/protobuf
+// backend, which resolves message types through gogo's static,
+// proto.RegisterType-based registry and so can't be pointed at a
+// descriptor loaded at runtime. Use harness for differential checks.
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/bufbuild/protocompile"
+
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/dynamicpb"
+
+	"protomon-fuzz-harness/internal/diffpath"
 )
 
 const maxInputSize = 100 * 1024 * 1024 // 100MB
 
+// recordSeparator delimits text and json records in the streaming format: a
+// line containing exactly this string and nothing else.
+const recordSeparator = "---"
+
+// maxVarintBytes is the longest a base-128 varint can be before it must be
+// malformed (ceil(64/7)).
+const maxVarintBytes = 10
+
+// Format identifiers accepted by --in and --out.
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatBinary = "binary"
+)
+
+// formatDiffJSON is the --diff value that switches roundtrip mismatch
+// reporting from two full textprotos to structured per-field records.
+const formatDiffJSON = "json"
+
 var prettyTextOptions = prototext.MarshalOptions{
 	Multiline: true,
 	Indent:    "  ",
 }
 
 var (
-	mode      = flag.String("mode", "encode", "Mode: 'encode' (text->binary), 'decode' (binary->text), or 'roundtrip'")
-	protoFile = flag.String("proto", "", "Path to .proto file")
-	message   = flag.String("message", "", "Fully qualified message name (e.g., package.MessageName)")
-	protoPath = flag.String("proto_path", "", "Proto import path (defaults to directory containing proto file)")
+	mode       = flag.String("mode", "encode", "Mode: 'encode', 'decode', 'roundtrip', 'compile', or the '-stream' variant of encode/decode/roundtrip")
+	protoFile  = flag.String("proto", "", "Path to .proto file")
+	message    = flag.String("message", "", "Fully qualified message name (e.g., package.MessageName)")
+	protoPath  = flag.String("proto_path", "", "Proto import path (defaults to directory containing proto file)")
+	keepGoing  = flag.Bool("keep-going", false, "in -stream modes, report per-record errors to stderr and keep processing instead of stopping at the first one")
+	inFormat   = flag.String("in", "", "Input format: 'text', 'json', or 'binary' (default depends on -mode)")
+	outFormat  = flag.String("out", "", "Output format: 'text', 'json', or 'binary' (default depends on -mode)")
+	diffFormat = flag.String("diff", "text", "Roundtrip mismatch report format: 'text' (two full textprotos) or 'json' (structured {path,kind,lhs,rhs} records on stderr, one per line)")
+
+	descriptorSetIn = flag.String("descriptor_set_in", "", "Path to a pre-built FileDescriptorSet (skips compilation and the descriptor cache entirely)")
+	compiler        = flag.String("compiler", "protocompile", "Proto compiler backend: 'protocompile' (pure Go, default, no protoc required) or 'protoc' (external binary)")
+
+	jsonDiscardUnknown  = flag.Bool("json_discard_unknown", false, "protojson.UnmarshalOptions.DiscardUnknown")
+	jsonAllowPartial    = flag.Bool("json_allow_partial", false, "protojson/prototext UnmarshalOptions.AllowPartial")
+	jsonUseProtoNames   = flag.Bool("json_use_proto_names", false, "protojson.MarshalOptions.UseProtoNames")
+	jsonEmitUnpopulated = flag.Bool("json_emit_unpopulated", false, "protojson.MarshalOptions.EmitUnpopulated")
 )
 
 func main() {
+	flag.BoolVar(keepGoing, "k", false, "shorthand for -keep-going")
 	flag.Parse()
 
-	if *protoFile == "" {
-		fmt.Fprintln(os.Stderr, "Error: --proto is required")
+	if *protoFile == "" && *descriptorSetIn == "" {
+		fmt.Fprintln(os.Stderr, "Error: --proto or --descriptor_set_in is required")
 		os.Exit(1)
 	}
+
+	if *mode == "compile" {
+		descBytes, err := loadDescriptorSetBytes(*protoFile, *protoPath, *descriptorSetIn, *compiler)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error compiling proto: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := os.Stdout.Write(descBytes); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *message == "" {
 		fmt.Fprintln(os.Stderr, "Error: --message is required")
 		os.Exit(1)
 	}
 
 	// Load the proto file and find the message descriptor
-	msgDesc, err := loadMessageDescriptor(*protoFile, *message, *protoPath)
+	msgDesc, err := loadMessageDescriptor(*protoFile, *message, *protoPath, *descriptorSetIn, *compiler)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading proto: %v\n", err)
 		os.Exit(1)
 	}
 
+	in, out := resolveFormats(*mode)
+
 	// Run the requested mode
 	switch *mode {
-	case "encode":
-		err = encode(msgDesc)
-	case "decode":
-		err = decode(msgDesc)
+	case "encode", "decode":
+		err = convert(msgDesc, in, out)
 	case "roundtrip":
-		err = roundtrip(msgDesc)
+		err = roundtrip(msgDesc, in, out)
+	case "encode-stream", "decode-stream":
+		err = convertStream(msgDesc, in, out)
+	case "roundtrip-stream":
+		err = roundtripStream(msgDesc, in, out)
+	case "differential":
+		fmt.Fprintln(os.Stderr, "Error: -mode=differential is not available in harness_dynamic: "+
+			"it cross-checks google.golang.org/protobuf against the gogo/protobuf backend, which "+
+			"requires a statically compiled, registered message type and can't be driven off a "+
+			"schema loaded at runtime. Use harness (the fixed-schema binary) for gogo differential "+
+			"checks against this message, instead.")
+		os.Exit(1)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown mode: %s\n", *mode)
 		os.Exit(1)
@@ -84,20 +197,153 @@ func main() {
 	}
 }
 
-// loadMessageDescriptor uses protoc to compile the proto file and returns the message descriptor.
-func loadMessageDescriptor(protoFile, messageName, protoPath string) (protoreflect.MessageDescriptor, error) {
-	// Get absolute path to proto file
+// loadMessageDescriptor resolves the FileDescriptorSet for protoFile (via
+// --descriptor_set_in, the descriptor cache, or the --compiler backend) and returns the
+// descriptor for messageName within it.
+func loadMessageDescriptor(protoFile, messageName, protoPath, descriptorSetIn, compiler string) (protoreflect.MessageDescriptor, error) {
+	descBytes, err := loadDescriptorSetBytes(protoFile, protoPath, descriptorSetIn, compiler)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the FileDescriptorSet
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(descBytes, fdSet); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor: %w", err)
+	}
+
+	// Build file descriptors and register them
+	files, err := protodesc.NewFiles(fdSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file descriptors: %w", err)
+	}
+
+	// Find the message descriptor
+	fullName := protoreflect.FullName(messageName)
+	desc, err := files.FindDescriptorByName(fullName)
+	if err != nil {
+		// List available messages
+		var available []string
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			msgs := fd.Messages()
+			for i := 0; i < msgs.Len(); i++ {
+				available = append(available, string(msgs.Get(i).FullName()))
+			}
+			return true
+		})
+		return nil, fmt.Errorf("message not found: %s\nAvailable: %s", messageName, strings.Join(available, ", "))
+	}
+
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", messageName)
+	}
+
+	return msgDesc, nil
+}
+
+// loadDescriptorSetBytes returns the raw FileDescriptorSet bytes for
+// protoFile: read straight from descriptorSetIn if given, served from the
+// on-disk descriptor cache on a hit, or compiled fresh with the requested
+// compiler backend (and written to the cache for next time) on a miss.
+func loadDescriptorSetBytes(protoFile, protoPath, descriptorSetIn, compiler string) ([]byte, error) {
+	if descriptorSetIn != "" {
+		data, err := os.ReadFile(descriptorSetIn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+		}
+		return data, nil
+	}
+
 	absProto, err := filepath.Abs(protoFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
-
-	// Default proto_path to the directory containing the proto file
 	if protoPath == "" {
 		protoPath = filepath.Dir(absProto)
 	}
 
-	// Create a temp file for the descriptor set
+	cachePath, err := descriptorCachePath(absProto, protoPath, compiler)
+	if err != nil {
+		// Caching is a best-effort optimization; fall back to compiling.
+		return compileProto(absProto, protoPath, compiler)
+	}
+
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		return cached, nil
+	}
+
+	descBytes, err := compileProto(absProto, protoPath, compiler)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+		_ = os.WriteFile(cachePath, descBytes, 0o644)
+	}
+	return descBytes, nil
+}
+
+// compileProto dispatches to the requested compiler backend, returning a
+// serialized FileDescriptorSet either way.
+func compileProto(absProto, protoPath, compiler string) ([]byte, error) {
+	switch compiler {
+	case "protoc":
+		return compileWithProtoc(absProto, protoPath)
+	case "protocompile", "":
+		return compileWithProtocompile(absProto, protoPath)
+	default:
+		return nil, fmt.Errorf("unknown --compiler: %s (want 'protocompile' or 'protoc')", compiler)
+	}
+}
+
+// compileWithProtocompile compiles protoFile (found at absProto) with the
+// pure-Go protocompile library, requiring no protoc installation, temp
+// files, or subprocess. The result is flattened into a FileDescriptorSet
+// (the compiled file plus all of its transitive imports) so that it can be
+// consumed identically to the protoc backend's output.
+func compileWithProtocompile(absProto, protoPath string) ([]byte, error) {
+	relProto, err := filepath.Rel(protoPath, absProto)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not under --proto_path=%s: %w", absProto, protoPath, err)
+	}
+
+	comp := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{protoPath},
+		}),
+		SourceInfoMode: protocompile.SourceInfoNone,
+	}
+
+	files, err := comp.Compile(context.Background(), relProto)
+	if err != nil {
+		return nil, fmt.Errorf("protocompile failed: %w", err)
+	}
+
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var addFile func(fd protoreflect.FileDescriptor)
+	addFile = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			addFile(imports.Get(i).FileDescriptor)
+		}
+		fdSet.File = append(fdSet.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range files {
+		addFile(fd)
+	}
+
+	return proto.Marshal(fdSet)
+}
+
+// compileWithProtoc shells out to protoc to compile protoFile (found at
+// absProto) into a FileDescriptorSet.
+func compileWithProtoc(absProto, protoPath string) ([]byte, error) {
 	tmpFile, err := os.CreateTemp("", "descriptor-*.pb")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp file: %w", err)
@@ -105,7 +351,6 @@ func loadMessageDescriptor(protoFile, messageName, protoPath string) (protorefle
 	tmpFile.Close()
 	defer os.Remove(tmpFile.Name())
 
-	// Run protoc to generate the descriptor set
 	cmd := exec.Command("protoc",
 		"--proto_path="+protoPath,
 		"--descriptor_set_out="+tmpFile.Name(),
@@ -118,46 +363,93 @@ func loadMessageDescriptor(protoFile, messageName, protoPath string) (protorefle
 		return nil, fmt.Errorf("protoc failed: %v\n%s", err, output)
 	}
 
-	// Read the descriptor set
 	descBytes, err := os.ReadFile(tmpFile.Name())
 	if err != nil {
 		return nil, fmt.Errorf("failed to read descriptor: %w", err)
 	}
+	return descBytes, nil
+}
 
-	// Parse the FileDescriptorSet
-	fdSet := &descriptorpb.FileDescriptorSet{}
-	if err := proto.Unmarshal(descBytes, fdSet); err != nil {
-		return nil, fmt.Errorf("failed to parse descriptor: %w", err)
+// importRegexp matches proto2/proto3 import statements, including the
+// "public" and "weak" modifiers.
+var importRegexp = regexp.MustCompile(`(?m)^\s*import\s+(?:public\s+|weak\s+)?"([^"]+)"\s*;`)
+
+// descriptorCachePath returns the cache file for absProto, keyed on its
+// content plus the mtimes of every import it transitively pulls in
+// (resolved against protoPath), so edits anywhere in the dependency graph
+// invalidate the entry. compiler is folded into the key too, since protoc
+// and protocompile can disagree on a given proto and must not share a
+// cache entry.
+func descriptorCachePath(absProto, protoPath, compiler string) (string, error) {
+	baseCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
 	}
 
-	// Build file descriptors and register them
-	files, err := protodesc.NewFiles(fdSet)
+	key, err := descriptorCacheKey(absProto, protoPath, compiler)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file descriptors: %w", err)
+		return "", err
 	}
+	return filepath.Join(baseCacheDir, "protomon", "descriptors", key+".pb"), nil
+}
 
-	// Find the message descriptor
-	fullName := protoreflect.FullName(messageName)
-	desc, err := files.FindDescriptorByName(fullName)
+func descriptorCacheKey(absProto, protoPath, compiler string) (string, error) {
+	data, err := os.ReadFile(absProto)
 	if err != nil {
-		// List available messages
-		var available []string
-		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
-			msgs := fd.Messages()
-			for i := 0; i < msgs.Len(); i++ {
-				available = append(available, string(msgs.Get(i).FullName()))
-			}
-			return true
-		})
-		return nil, fmt.Errorf("message not found: %s\nAvailable: %s", messageName, strings.Join(available, ", "))
+		return "", err
 	}
 
-	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
-	if !ok {
-		return nil, fmt.Errorf("%s is not a message type", messageName)
+	h := sha256.New()
+	h.Write(data)
+	fmt.Fprintf(h, "compiler:%s\n", compiler)
+
+	imports, err := transitiveImports(absProto, protoPath)
+	if err != nil {
+		return "", err
 	}
 
-	return msgDesc, nil
+	for _, imp := range imports {
+		info, err := os.Stat(imp)
+		if err != nil {
+			// Let the compiler report the missing import; don't fail the
+			// cache lookup over it.
+			continue
+		}
+		fmt.Fprintf(h, "%s:%d\n", imp, info.ModTime().UnixNano())
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// transitiveImports returns every proto file absProto imports, directly or
+// transitively, resolved against protoPath, deduplicated and sorted, so a
+// change two or more import hops away still invalidates the cache.
+func transitiveImports(absProto, protoPath string) ([]string, error) {
+	seen := map[string]bool{absProto: true}
+	var imports []string
+
+	var visit func(path string)
+	visit = func(path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// Let the compiler report the missing import; don't fail the
+			// cache lookup over it.
+			return
+		}
+		for _, m := range importRegexp.FindAllStringSubmatch(string(data), -1) {
+			imp := filepath.Join(protoPath, m[1])
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
+			imports = append(imports, imp)
+			visit(imp)
+		}
+	}
+	visit(absProto)
+
+	sort.Strings(imports)
+	return imports, nil
 }
 
 // readLimited reads from r with a size limit.
@@ -178,58 +470,123 @@ func newMessage(desc protoreflect.MessageDescriptor) *dynamicpb.Message {
 	return dynamicpb.NewMessage(desc)
 }
 
-func encode(msgDesc protoreflect.MessageDescriptor) error {
-	textInput, err := readLimited(os.Stdin)
-	if err != nil {
-		return fmt.Errorf("failed to read stdin: %w", err)
+// resolveFormats fills in --in/--out defaults for modes that didn't specify
+// them explicitly, preserving each mode's historical direction.
+func resolveFormats(mode string) (in, out string) {
+	in, out = *inFormat, *outFormat
+	switch mode {
+	case "encode", "encode-stream":
+		if in == "" {
+			in = formatText
+		}
+		if out == "" {
+			out = formatBinary
+		}
+	case "decode", "decode-stream":
+		if in == "" {
+			in = formatBinary
+		}
+		if out == "" {
+			out = formatText
+		}
+	case "roundtrip", "roundtrip-stream":
+		if in == "" {
+			in = formatText
+		}
+		if out == "" {
+			out = formatBinary
+		}
 	}
+	return in, out
+}
 
-	msg := newMessage(msgDesc)
-	if err := prototext.Unmarshal(textInput, msg); err != nil {
-		return fmt.Errorf("failed to parse text format: %w", err)
+// jsonUnmarshalOptions and jsonMarshalOptions build fresh options values
+// per call from the --json_* flags, so they see the values set by
+// flag.Parse.
+func jsonUnmarshalOptions() protojson.UnmarshalOptions {
+	return protojson.UnmarshalOptions{
+		DiscardUnknown: *jsonDiscardUnknown,
+		AllowPartial:   *jsonAllowPartial,
 	}
+}
 
-	binaryOutput, err := proto.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to serialize: %w", err)
+func jsonMarshalOptions() protojson.MarshalOptions {
+	return protojson.MarshalOptions{
+		Multiline:       true,
+		Indent:          "  ",
+		UseProtoNames:   *jsonUseProtoNames,
+		EmitUnpopulated: *jsonEmitUnpopulated,
 	}
+}
 
-	if _, err := os.Stdout.Write(binaryOutput); err != nil {
-		return fmt.Errorf("failed to write output: %w", err)
+// unmarshalMsg decodes data into msg using the given format.
+func unmarshalMsg(format string, data []byte, msg proto.Message) error {
+	switch format {
+	case formatText:
+		return (prototext.UnmarshalOptions{AllowPartial: *jsonAllowPartial}).Unmarshal(data, msg)
+	case formatJSON:
+		return jsonUnmarshalOptions().Unmarshal(data, msg)
+	case formatBinary:
+		return proto.Unmarshal(data, msg)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
 	}
+}
 
-	return nil
+// marshalMsg encodes msg using the given format.
+func marshalMsg(format string, msg proto.Message) ([]byte, error) {
+	switch format {
+	case formatText:
+		return prettyTextOptions.Marshal(msg)
+	case formatJSON:
+		return jsonMarshalOptions().Marshal(msg)
+	case formatBinary:
+		return proto.Marshal(msg)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
 }
 
-func decode(msgDesc protoreflect.MessageDescriptor) error {
-	binaryInput, err := readLimited(os.Stdin)
+// convert reads one record in format in from stdin and writes it re-encoded
+// in format out to stdout. This backs both --mode=encode and --mode=decode,
+// which differ only in their default formats.
+func convert(msgDesc protoreflect.MessageDescriptor, in, out string) error {
+	data, err := readLimited(os.Stdin)
 	if err != nil {
 		return fmt.Errorf("failed to read stdin: %w", err)
 	}
 
 	msg := newMessage(msgDesc)
-	if err := proto.Unmarshal(binaryInput, msg); err != nil {
-		return fmt.Errorf("failed to parse binary: %w", err)
+	if err := unmarshalMsg(in, data, msg); err != nil {
+		return fmt.Errorf("failed to parse %s input: %w", in, err)
 	}
 
-	textOutput, err := prettyTextOptions.Marshal(msg)
+	encoded, err := marshalMsg(out, msg)
 	if err != nil {
-		return fmt.Errorf("failed to print text format: %w", err)
+		return fmt.Errorf("failed to marshal %s output: %w", out, err)
+	}
+
+	if _, err := os.Stdout.Write(encoded); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
 	}
 
-	fmt.Print(string(textOutput))
 	return nil
 }
 
-func roundtrip(msgDesc protoreflect.MessageDescriptor) error {
-	textInput, err := readLimited(os.Stdin)
+// roundtrip parses one record in format in, re-encodes it through a binary
+// intermediate, and checks that decoding the intermediate reproduces the
+// same message, before writing it out in format out. Differing in/out
+// formats turn this into a cross-format differential check (e.g.
+// --in=json --out=text exercises json->binary->text).
+func roundtrip(msgDesc protoreflect.MessageDescriptor, in, out string) error {
+	data, err := readLimited(os.Stdin)
 	if err != nil {
 		return fmt.Errorf("failed to read stdin: %w", err)
 	}
 
 	msg1 := newMessage(msgDesc)
-	if err := prototext.Unmarshal(textInput, msg1); err != nil {
-		return fmt.Errorf("failed to parse text format: %w", err)
+	if err := unmarshalMsg(in, data, msg1); err != nil {
+		return fmt.Errorf("failed to parse %s input: %w", in, err)
 	}
 
 	binary, err := proto.Marshal(msg1)
@@ -243,6 +600,10 @@ func roundtrip(msgDesc protoreflect.MessageDescriptor) error {
 	}
 
 	if !proto.Equal(msg1, msg2) {
+		if *diffFormat == formatDiffJSON {
+			return reportJSONDiff(msg1, msg2)
+		}
+
 		text1, err1 := prettyTextOptions.Marshal(msg1)
 		text2, err2 := prettyTextOptions.Marshal(msg2)
 
@@ -260,7 +621,11 @@ func roundtrip(msgDesc protoreflect.MessageDescriptor) error {
 			originalText, roundtripText)
 	}
 
-	if _, err := os.Stdout.Write(binary); err != nil {
+	encoded, err := marshalMsg(out, msg2)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s output: %w", out, err)
+	}
+	if _, err := os.Stdout.Write(encoded); err != nil {
 		return fmt.Errorf("failed to write output: %w", err)
 	}
 
@@ -268,3 +633,273 @@ func roundtrip(msgDesc protoreflect.MessageDescriptor) error {
 	return nil
 }
 
+// reportJSONDiff emits one structured {path,kind,lhs,rhs} record per line to
+// stderr for every field where lhs and rhs disagree, then returns a summary
+// error. This gives fuzz drivers a stable signature to dedupe and minimize
+// crashes against, instead of diffing two opaque textproto blobs.
+func reportJSONDiff(lhs, rhs proto.Message) error {
+	mismatches := diffpath.Diff("", lhs.ProtoReflect(), rhs.ProtoReflect())
+
+	enc := json.NewEncoder(os.Stderr)
+	for _, m := range mismatches {
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("failed to encode diff record: %w", err)
+		}
+	}
+
+	return fmt.Errorf("roundtrip mismatch: %d field path(s) differ (see -diff=json records on stderr)", len(mismatches))
+}
+
+// convertStream reads a sequence of records in format in from stdin and
+// writes each re-encoded in format out to stdout. This backs both
+// --mode=encode-stream and --mode=decode-stream.
+func convertStream(msgDesc protoreflect.MessageDescriptor, in, out string) error {
+	records, err := readStreamRecords(in, os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	var written, failed int
+	for i, rec := range records {
+		msg := newMessage(msgDesc)
+		if err := unmarshalMsg(in, rec, msg); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to parse %s input: %v\n", i, in, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		encoded, err := marshalMsg(out, msg)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to marshal %s output: %v\n", i, out, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		if err := writeStreamRecord(w, out, written, encoded); err != nil {
+			return fmt.Errorf("record %d: failed to write output: %w", i, err)
+		}
+		written++
+	}
+
+	fmt.Fprintf(os.Stderr, "%d/%d records OK\n", len(records)-failed, len(records))
+	if failed > 0 {
+		return fmt.Errorf("%d/%d records failed", failed, len(records))
+	}
+	return nil
+}
+
+// roundtripStream runs the roundtrip check independently over each record
+// in an in-format stream, writing the re-encoded out-format output for
+// every record that round-trips cleanly.
+func roundtripStream(msgDesc protoreflect.MessageDescriptor, in, out string) error {
+	records, err := readStreamRecords(in, os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	var written, failed int
+	for i, rec := range records {
+		msg1 := newMessage(msgDesc)
+		if err := unmarshalMsg(in, rec, msg1); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to parse %s input: %v\n", i, in, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		binary, err := proto.Marshal(msg1)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to serialize: %v\n", i, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		msg2 := newMessage(msgDesc)
+		if err := proto.Unmarshal(binary, msg2); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to parse binary: %v\n", i, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		if !proto.Equal(msg1, msg2) {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: roundtrip mismatch\n", i)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+
+		encoded, err := marshalMsg(out, msg2)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d: failed to marshal %s output: %v\n", i, out, err)
+			if !*keepGoing {
+				return fmt.Errorf("stopped at record %d (use -keep-going to continue past errors)", i)
+			}
+			continue
+		}
+		if err := writeStreamRecord(w, out, written, encoded); err != nil {
+			return fmt.Errorf("record %d: failed to write output: %w", i, err)
+		}
+		written++
+	}
+
+	fmt.Fprintf(os.Stderr, "%d/%d records OK\n", len(records)-failed, len(records))
+	if failed > 0 {
+		return fmt.Errorf("%d/%d records failed", failed, len(records))
+	}
+	return nil
+}
+
+// readStreamRecords reads every record from r according to format, returning
+// each as raw encoded bytes ready for unmarshalMsg.
+func readStreamRecords(format string, r io.Reader) ([][]byte, error) {
+	if format == formatBinary {
+		var records [][]byte
+		for {
+			rec, err := readDelimited(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+		return records, nil
+	}
+
+	data, err := readLimited(r)
+	if err != nil {
+		return nil, err
+	}
+	return splitTextRecords(data), nil
+}
+
+// writeStreamRecord writes one record already encoded in format to w. index
+// is the number of records already written successfully, used to decide
+// whether a separator is needed before this one.
+func writeStreamRecord(w io.Writer, format string, index int, data []byte) error {
+	if format == formatBinary {
+		return writeDelimited(w, data)
+	}
+	if index > 0 {
+		if _, err := fmt.Fprintln(w, recordSeparator); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitTextRecords splits data into text or json records delimited by a
+// line containing only recordSeparator.
+func splitTextRecords(data []byte) [][]byte {
+	var records [][]byte
+	var cur bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == recordSeparator {
+			records = append(records, append([]byte(nil), cur.Bytes()...))
+			cur.Reset()
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+	}
+	if cur.Len() > 0 {
+		records = append(records, append([]byte(nil), cur.Bytes()...))
+	}
+	return records
+}
+
+// writeDelimited writes msg to w prefixed with its length as a base-128
+// varint, matching the wire format read by encoding/protodelim.
+func writeDelimited(w io.Writer, msg []byte) error {
+	header := protowire.AppendVarint(nil, uint64(len(msg)))
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write length header: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write payload: %w", err)
+	}
+	return nil
+}
+
+// readDelimited reads one varint length-delimited record from r. The
+// length header is read strictly one byte at a time and unbuffered (no
+// bufio.Reader), so reading a header never pulls in bytes past it; a
+// record whose length prefix is itself corrupt still takes its payload
+// from whatever bytes follow. Returns io.EOF only when zero bytes
+// could be read for a new record's header.
+func readDelimited(r io.Reader) ([]byte, error) {
+	size, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxInputSize {
+		return nil, fmt.Errorf("record size %d exceeds maximum of %d bytes", size, maxInputSize)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("failed to read %d-byte payload: %w", size, err)
+	}
+	return payload, nil
+}
+
+// readVarint decodes a single base-128 varint from r, reading exactly one
+// byte at a time via individual r.Read calls.
+func readVarint(r io.Reader) (uint64, error) {
+	var buf []byte
+	var b [1]byte
+	for len(buf) < maxVarintBytes {
+		n, err := r.Read(b[:])
+		if n == 0 {
+			if err != nil {
+				if err == io.EOF && len(buf) == 0 {
+					return 0, io.EOF
+				}
+				return 0, fmt.Errorf("truncated varint header: %w", err)
+			}
+			continue
+		}
+		buf = append(buf, b[0])
+
+		if v, n := protowire.ConsumeVarint(buf); n > 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("malformed varint: exceeded %d bytes", maxVarintBytes)
+}